@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/auth"
+)
+
+// contextFS abstracts the filesystem calls writeDockerContext needs, so
+// tests can verify the written layout against an in-memory fake instead
+// of touching a real ~/.docker directory.
+type contextFS interface {
+	MkdirAll(path string, perm os.FileMode) error
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	ReadFile(path string) ([]byte, error)
+}
+
+// osContextFS is the default contextFS, backed by the real filesystem.
+type osContextFS struct{}
+
+func (osContextFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osContextFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(path, data, perm)
+}
+
+func (osContextFS) ReadFile(path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+var defaultContextFS contextFS = osContextFS{}
+
+// dockerContextEndpoint and dockerContextMetadata mirror the subset of
+// the `docker context` meta.json layout that `machine env --context`
+// needs to populate: a single "docker" endpoint pointing at the
+// machine's daemon.
+type dockerContextEndpoint struct {
+	Host          string `json:"Host"`
+	SkipTLSVerify bool   `json:"SkipTLSVerify"`
+}
+
+type dockerContextMetadata struct {
+	Name      string                           `json:"Name"`
+	Metadata  struct{}                         `json:"Metadata"`
+	Endpoints map[string]dockerContextEndpoint `json:"Endpoints"`
+}
+
+// dockerConfigDir returns the root of the user's Docker CLI
+// configuration, i.e. the directory containing `contexts/`.
+func dockerConfigDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker"), nil
+}
+
+// dockerContextID is the content-addressed directory name the Docker CLI
+// uses for a context's metadata and TLS material: the hex SHA-256 digest
+// of its name.
+func dockerContextID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeDockerContext writes a `docker context` definition named name
+// under dockerConfigDir, pointing at dockerHost and carrying the TLS
+// material from authOptions, in the layout `docker context ls`/`docker
+// context use` expect to find it in.
+func writeDockerContext(fs contextFS, dockerConfigDir, name, dockerHost string, authOptions *auth.Options) error {
+	contextID := dockerContextID(name)
+
+	metaDir := filepath.Join(dockerConfigDir, "contexts", "meta", contextID)
+	if err := fs.MkdirAll(metaDir, 0755); err != nil {
+		return err
+	}
+
+	metadata := dockerContextMetadata{
+		Name: name,
+		Endpoints: map[string]dockerContextEndpoint{
+			"docker": {Host: dockerHost, SkipTLSVerify: false},
+		},
+	}
+	metaJSON, err := json.MarshalIndent(metadata, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := fs.WriteFile(filepath.Join(metaDir, "meta.json"), metaJSON, 0644); err != nil {
+		return err
+	}
+
+	if authOptions == nil {
+		return nil
+	}
+
+	tlsDir := filepath.Join(dockerConfigDir, "contexts", "tls", contextID, "docker")
+	if err := fs.MkdirAll(tlsDir, 0755); err != nil {
+		return err
+	}
+
+	tlsFiles := map[string]string{
+		"ca.pem":   authOptions.CaCertPath,
+		"cert.pem": authOptions.ClientCertPath,
+		"key.pem":  authOptions.ClientKeyPath,
+	}
+	for destName, srcPath := range tlsFiles {
+		if srcPath == "" {
+			continue
+		}
+		data, err := fs.ReadFile(srcPath)
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %s", srcPath, err)
+		}
+		if err := fs.WriteFile(filepath.Join(tlsDir, destName), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cmdEnvContext handles `machine env --context <name> <machine>`: instead
+// of printing shell exports, it writes a `docker context` definition
+// pointing at the machine and prints the one-line hint to activate it.
+func cmdEnvContext(c CommandLine, api libmachine.API, contextName string) error {
+	if len(c.Args()) != 1 {
+		return errImproperEnvArgs
+	}
+	machineName := c.Args()[0]
+
+	h, err := api.Load(machineName)
+	if err != nil {
+		return err
+	}
+
+	dockerHost, authOptions, err := defaultConnChecker.Check(h, c.Bool("swarm"))
+	if err != nil {
+		return err
+	}
+
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return err
+	}
+
+	if err := writeDockerContext(defaultContextFS, configDir, contextName, dockerHost, authOptions); err != nil {
+		return err
+	}
+
+	fmt.Printf("docker context use %s\n", contextName)
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/persist/persisttest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeContextFS is an in-memory contextFS, so tests can assert on the
+// exact paths and contents writeDockerContext produces without touching
+// a real ~/.docker directory.
+type fakeContextFS struct {
+	dirs  map[string]os.FileMode
+	files map[string][]byte
+}
+
+func newFakeContextFS() *fakeContextFS {
+	return &fakeContextFS{
+		dirs:  map[string]os.FileMode{},
+		files: map[string][]byte{},
+	}
+}
+
+func (fs *fakeContextFS) MkdirAll(path string, perm os.FileMode) error {
+	fs.dirs[path] = perm
+	return nil
+}
+
+func (fs *fakeContextFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	fs.files[path] = data
+	return nil
+}
+
+func (fs *fakeContextFS) ReadFile(path string) ([]byte, error) {
+	data, ok := fs.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+func TestWriteDockerContext(t *testing.T) {
+	const (
+		caPath   = "/certs/quux/ca.pem"
+		certPath = "/certs/quux/cert.pem"
+		keyPath  = "/certs/quux/key.pem"
+	)
+
+	fs := newFakeContextFS()
+	fs.files[caPath] = []byte("ca-data")
+	fs.files[certPath] = []byte("cert-data")
+	fs.files[keyPath] = []byte("key-data")
+
+	authOptions := &auth.Options{
+		CaCertPath:     caPath,
+		ClientCertPath: certPath,
+		ClientKeyPath:  keyPath,
+	}
+
+	err := writeDockerContext(fs, "/home/user/.docker", "quux", "tcp://1.2.3.4:2376", authOptions)
+	assert.NoError(t, err)
+
+	contextID := dockerContextID("quux")
+	metaPath := filepath.Join("/home/user/.docker", "contexts", "meta", contextID, "meta.json")
+	tlsDir := filepath.Join("/home/user/.docker", "contexts", "tls", contextID, "docker")
+
+	metaJSON, ok := fs.files[metaPath]
+	if assert.True(t, ok, "expected meta.json to be written at %s", metaPath) {
+		var metadata dockerContextMetadata
+		assert.NoError(t, json.Unmarshal(metaJSON, &metadata))
+		assert.Equal(t, "quux", metadata.Name)
+		assert.Equal(t, "tcp://1.2.3.4:2376", metadata.Endpoints["docker"].Host)
+		assert.False(t, metadata.Endpoints["docker"].SkipTLSVerify)
+	}
+
+	assert.Equal(t, []byte("ca-data"), fs.files[filepath.Join(tlsDir, "ca.pem")])
+	assert.Equal(t, []byte("cert-data"), fs.files[filepath.Join(tlsDir, "cert.pem")])
+	assert.Equal(t, []byte("key-data"), fs.files[filepath.Join(tlsDir, "key.pem")])
+}
+
+func TestWriteDockerContextWithoutAuthOptions(t *testing.T) {
+	fs := newFakeContextFS()
+
+	err := writeDockerContext(fs, "/home/user/.docker", "quux", "tcp://1.2.3.4:2376", nil)
+	assert.NoError(t, err)
+
+	contextID := dockerContextID("quux")
+	metaPath := filepath.Join("/home/user/.docker", "contexts", "meta", contextID, "meta.json")
+	_, ok := fs.files[metaPath]
+	assert.True(t, ok)
+
+	tlsDir := filepath.Join("/home/user/.docker", "contexts", "tls", contextID, "docker")
+	for path := range fs.files {
+		assert.False(t, filepath.Dir(path) == tlsDir, "no TLS material should be written without auth options")
+	}
+}
+
+func TestCmdEnvContext(t *testing.T) {
+	fs := newFakeContextFS()
+	defer func(old contextFS) { defaultContextFS = old }(defaultContextFS)
+	defaultContextFS = fs
+
+	defer func(old ConnChecker) { defaultConnChecker = old }(defaultConnChecker)
+	defaultConnChecker = &FakeConnChecker{
+		DockerHost: "tcp://1.2.3.4:2376",
+	}
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"quux"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"context": "quux",
+				"swarm":   false,
+			},
+		},
+	}
+	api := libmachine.API(&libmachinetest.FakeAPI{
+		FakeStore: &persisttest.FakeStore{
+			Hosts: []*host.Host{
+				{
+					Name:   "quux",
+					Driver: &fakedriver.Driver{},
+				},
+			},
+		},
+	})
+
+	assert.NoError(t, cmdEnvContext(commandLine, api, "quux"))
+
+	contextID := dockerContextID("quux")
+	metaPath := filepath.Join(mustDockerConfigDir(t), "contexts", "meta", contextID, "meta.json")
+	_, ok := fs.files[metaPath]
+	assert.True(t, ok)
+}
+
+func mustDockerConfigDir(t *testing.T) string {
+	dir, err := dockerConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,378 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/commands/shell"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/host"
+)
+
+var (
+	errImproperEnvArgs      = errors.New("Error: Expected one machine name as an argument")
+	errImproperUnsetEnvArgs = errors.New("Error: Expected no machine name when the -u flag is present")
+)
+
+// ShellConfig holds the set of environment variables produced by
+// `machine env`. Turning it into shell-specific output is the job of a
+// ShellRenderer (see shell_templates.go), not of this struct.
+type ShellConfig struct {
+	DockerCertPath  string
+	DockerHost      string
+	DockerTLSVerify string
+	MachineName     string
+	NoProxyVar      string
+	NoProxyValue    string
+}
+
+// UsageHintGenerator renders the comment that is printed above the shell
+// output to remind the user how to actually apply it (e.g. via `eval`).
+type UsageHintGenerator interface {
+	GenerateUsageHint(userShell string, args []string) string
+}
+
+// EnvUsageHintGenerator is the default UsageHintGenerator, producing a
+// hint in the syntax of the target shell.
+type EnvUsageHintGenerator struct{}
+
+func (ehg *EnvUsageHintGenerator) GenerateUsageHint(userShell string, args []string) string {
+	cmdLine := strings.Join(args, " ")
+
+	comment := "#"
+	switch userShell {
+	case "fish":
+		cmdLine = fmt.Sprintf("eval (%s)", cmdLine)
+	case "powershell":
+		cmdLine = fmt.Sprintf("%s | Invoke-Expression", cmdLine)
+	case "cmd":
+		comment = "REM"
+		cmdLine = fmt.Sprintf("\tFOR /f \"tokens=*\" %%i IN ('%s') DO %%i", cmdLine)
+	default:
+		cmdLine = fmt.Sprintf("eval \"$(%s)\"", cmdLine)
+	}
+
+	return fmt.Sprintf("%s Run this command to configure your shell: \n%s %s\n", comment, comment, cmdLine)
+}
+
+var defaultUsageHinter UsageHintGenerator = &EnvUsageHintGenerator{}
+
+// ConnChecker validates that a host is reachable and returns the Docker
+// host URL and TLS auth options to use when talking to it.
+type ConnChecker interface {
+	Check(h *host.Host, swarm bool) (string, *auth.Options, error)
+}
+
+// EnvConnChecker is the default ConnChecker, backed by libmachine/check.
+type EnvConnChecker struct{}
+
+func (ecc *EnvConnChecker) Check(h *host.Host, swarm bool) (string, *auth.Options, error) {
+	return check.GetHostDockerDetails(h, swarm)
+}
+
+var defaultConnChecker ConnChecker = &EnvConnChecker{}
+
+// resolveShell returns the shell the output should be rendered for: the
+// one explicitly requested via --shell, or the shell package's best
+// guess otherwise.
+func resolveShell(commandLine CommandLine) string {
+	if userShell := commandLine.String("shell"); userShell != "" {
+		return userShell
+	}
+
+	name, _, err := shell.Detect()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+func lookupNoProxyVar() (string, string) {
+	// NO_PROXY is the common name, but some tools/shells use the
+	// lowercase form instead, so we prefer whichever is already set.
+	if value := os.Getenv("NO_PROXY"); value != "" {
+		return "NO_PROXY", value
+	}
+	if value := os.Getenv("no_proxy"); value != "" {
+		return "no_proxy", value
+	}
+	return "NO_PROXY", ""
+}
+
+func shellCfgSet(commandLine CommandLine, api libmachine.API) (*ShellConfig, error) {
+	if len(commandLine.Args()) != 1 {
+		return nil, errImproperEnvArgs
+	}
+
+	machineName := commandLine.Args()[0]
+
+	host, err := api.Load(machineName)
+	if err != nil {
+		return nil, err
+	}
+
+	dockerHost, _, err := defaultConnChecker.Check(host, commandLine.Bool("swarm"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Snapshot whatever DOCKER_*/NO_PROXY values are already in effect
+	// before we overlay our own, so `env --unset` can restore them later
+	// instead of just unsetting ours on top of them. Skip this if the
+	// calling shell is already configured for this machine, or a
+	// snapshot already exists for it: otherwise a second `eval $(machine
+	// env machineName)` (or any shell init that re-sources it) would
+	// read machineName's own values back out of the environment and
+	// clobber the restore point, breaking `--unset`.
+	if os.Getenv("DOCKER_MACHINE_NAME") != machineName && !envSnapshotExists(machineName) {
+		saveEnvSnapshot(machineName, captureEnvSnapshot(machineName))
+	}
+
+	shellCfg := &ShellConfig{
+		DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), machineName),
+		DockerHost:      dockerHost,
+		DockerTLSVerify: "1",
+		MachineName:     machineName,
+	}
+
+	if commandLine.Bool("no-proxy") {
+		ip, err := host.Driver.GetIP()
+		if err != nil {
+			return nil, fmt.Errorf("Error getting host IP: %s", err)
+		}
+
+		proxyCfg, err := defaultProxyConfigSource.Get(machineName)
+		if err != nil {
+			return nil, err
+		}
+
+		shellCfg.NoProxyVar = "NO_PROXY"
+		shellCfg.NoProxyValue = resolveNoProxy(proxyCfg, ip)
+	}
+
+	return shellCfg, nil
+}
+
+func shellCfgUnset(commandLine CommandLine, api libmachine.API) (*ShellConfig, error) {
+	if len(commandLine.Args()) != 0 {
+		return nil, errImproperUnsetEnvArgs
+	}
+
+	return &ShellConfig{}, nil
+}
+
+// envRenderSet computes the ShellConfig for `machine env` and renders it
+// in the requested shell's syntax.
+func envRenderSet(commandLine CommandLine, api libmachine.API) (string, error) {
+	shellCfg, err := shellCfgSet(commandLine, api)
+	if err != nil {
+		return "", err
+	}
+	return rendererForShell(resolveShell(commandLine)).RenderSet(*shellCfg)
+}
+
+// envRenderUnset computes the ShellConfig for `machine env --unset` and
+// renders it in the requested shell's syntax. If a snapshot exists for
+// the machine named by $DOCKER_MACHINE_NAME (left behind by a prior
+// `machine env`) and it records a prior DOCKER_HOST, its values are
+// restored instead of merely unsetting ours on top of them.
+func envRenderUnset(commandLine CommandLine, api libmachine.API) (string, error) {
+	shellCfg, err := shellCfgUnset(commandLine, api)
+	if err != nil {
+		return "", err
+	}
+
+	renderer := rendererForShell(resolveShell(commandLine))
+
+	if snapshot := loadEnvSnapshot(); snapshot != nil && snapshot.DockerHost != "" {
+		return renderer.RenderSet(*snapshot)
+	}
+
+	return renderer.RenderUnset(*shellCfg)
+}
+
+func cmdEnv(c CommandLine, api libmachine.API) error {
+	if contextName := c.String("context"); contextName != "" {
+		return cmdEnvContext(c, api, contextName)
+	}
+
+	var (
+		rendered string
+		err      error
+	)
+
+	if c.Bool("unset") {
+		rendered, err = envRenderUnset(c, api)
+	} else {
+		rendered, err = envRenderSet(c, api)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stderr, defaultUsageHinter.GenerateUsageHint(resolveShell(c), os.Args))
+	fmt.Print(rendered)
+
+	return nil
+}
+
+var EnvCommand = cli.Command{
+	Name:   "env",
+	Usage:  "Display the commands to set up the environment for the Docker client",
+	Action: runCommand(cmdEnv),
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "shell",
+			Usage: "Force environment to be configured for a specified shell: [fish, cmd, powershell, tcsh], default is auto-detect",
+		},
+		cli.BoolFlag{
+			Name:  "unset, u",
+			Usage: "Unset variables instead of setting them",
+		},
+		cli.BoolFlag{
+			Name:  "no-proxy",
+			Usage: "Add machine IP to NO_PROXY environment variable",
+		},
+		cli.BoolFlag{
+			Name:  "swarm",
+			Usage: "Display the Swarm config instead of the Docker daemon",
+		},
+		cli.StringFlag{
+			Name:  "context",
+			Usage: "Write a `docker context` definition with this name instead of printing shell exports",
+		},
+	},
+}
+
+// podmanShellConfig holds the set of environment variables rendered by
+// `machine podman-env`. It mirrors ShellConfig but targets a podman
+// daemon reachable over SSH instead of a TLS-secured Docker daemon.
+type podmanShellConfig struct {
+	ContainerHost       string
+	ContainerSSHKey     string
+	PodmanVarlinkBridge string
+	UsageHint           string
+	MachineName         string
+}
+
+func podmanShellCfgSet(commandLine CommandLine, api libmachine.API) (*podmanShellConfig, error) {
+	if len(commandLine.Args()) != 1 {
+		return nil, errImproperEnvArgs
+	}
+
+	machineName := commandLine.Args()[0]
+
+	host, err := api.Load(machineName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reuse the same connectivity check the Docker env command relies
+	// on; we only care that the host is up, the returned Docker host
+	// URL itself is not used here.
+	if _, _, err := defaultConnChecker.Check(host, commandLine.Bool("swarm")); err != nil {
+		return nil, err
+	}
+
+	sshHost, err := host.Driver.GetSSHHostname()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting host SSH hostname: %s", err)
+	}
+
+	sshPort, err := host.Driver.GetSSHPort()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting host SSH port: %s", err)
+	}
+
+	sshUser := host.Driver.GetSSHUsername()
+	sshKeyPath := host.Driver.GetSSHKeyPath()
+
+	userShell := resolveShell(commandLine)
+
+	shellCfg := &podmanShellConfig{
+		ContainerHost:   fmt.Sprintf("ssh://%s@%s:%d/run/podman/podman.sock", sshUser, sshHost, sshPort),
+		ContainerSSHKey: sshKeyPath,
+		UsageHint:       defaultUsageHinter.GenerateUsageHint(userShell, os.Args),
+		MachineName:     machineName,
+	}
+
+	if commandLine.Bool("varlink") {
+		shellCfg.PodmanVarlinkBridge = fmt.Sprintf("ssh -p %d -i %s %s@%s -- sudo varlink bridge", sshPort, sshKeyPath, sshUser, sshHost)
+	}
+
+	return shellCfg, nil
+}
+
+func podmanShellCfgUnset(commandLine CommandLine, api libmachine.API) (*podmanShellConfig, error) {
+	if len(commandLine.Args()) != 0 {
+		return nil, errImproperUnsetEnvArgs
+	}
+
+	return &podmanShellConfig{
+		UsageHint: defaultUsageHinter.GenerateUsageHint(resolveShell(commandLine), os.Args),
+	}, nil
+}
+
+func cmdPodmanEnv(c CommandLine, api libmachine.API) error {
+	var (
+		shellCfg *podmanShellConfig
+		err      error
+	)
+
+	if c.Bool("unset") {
+		shellCfg, err = podmanShellCfgUnset(c, api)
+	} else {
+		shellCfg, err = podmanShellCfgSet(c, api)
+	}
+	if err != nil {
+		return err
+	}
+
+	renderer := podmanRendererForShell(resolveShell(c))
+
+	var rendered string
+	if c.Bool("unset") {
+		rendered, err = renderer.RenderUnset(*shellCfg)
+	} else {
+		rendered, err = renderer.RenderSet(*shellCfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stderr, shellCfg.UsageHint)
+	fmt.Print(rendered)
+
+	return nil
+}
+
+var PodmanEnvCommand = cli.Command{
+	Name:   "podman-env",
+	Usage:  "Display the commands to set up the environment for a Podman client talking to this machine over SSH",
+	Action: runCommand(cmdPodmanEnv),
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "shell",
+			Usage: "Force environment to be configured for a specified shell: [fish, cmd, powershell, tcsh], default is auto-detect",
+		},
+		cli.BoolFlag{
+			Name:  "unset, u",
+			Usage: "Unset variables instead of setting them",
+		},
+		cli.BoolFlag{
+			Name:  "varlink",
+			Usage: "Also emit PODMAN_VARLINK_BRIDGE alongside the CONTAINER_HOST socket URL",
+		},
+		cli.BoolFlag{
+			Name:  "swarm",
+			Usage: "Check connectivity against the Swarm master instead of the Docker daemon; the output is unchanged",
+		},
+	},
+}
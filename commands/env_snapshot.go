@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/commands/mcndirs"
+)
+
+// envSnapshotFile is the name of the file, relative to a machine's
+// directory, that records the Docker client environment variables in
+// effect the first time `machine env <name>` ran for it; later runs leave
+// an existing file as it is. `machine env --unset` reads it back to restore those values instead of simply
+// unsetting ours on top of them.
+const envSnapshotFile = "env.json"
+
+func envSnapshotPath(machineName string) string {
+	return filepath.Join(mcndirs.GetMachineDir(), machineName, envSnapshotFile)
+}
+
+// captureEnvSnapshot reads the Docker client environment variables (and
+// NO_PROXY) as they stand in the current process environment, i.e.
+// before `machine env` overlays its own values via eval in the calling
+// shell.
+func captureEnvSnapshot(machineName string) ShellConfig {
+	snapshot := ShellConfig{
+		DockerTLSVerify: os.Getenv("DOCKER_TLS_VERIFY"),
+		DockerHost:      os.Getenv("DOCKER_HOST"),
+		DockerCertPath:  os.Getenv("DOCKER_CERT_PATH"),
+		MachineName:     os.Getenv("DOCKER_MACHINE_NAME"),
+	}
+
+	// Only record NoProxyVar/NoProxyValue if a prior value actually
+	// existed; otherwise restoring this snapshot would emit a spurious
+	// `export NO_PROXY=""`.
+	if noProxyVar, noProxyValue := lookupNoProxyVar(); noProxyValue != "" {
+		snapshot.NoProxyVar = noProxyVar
+		snapshot.NoProxyValue = noProxyValue
+	}
+
+	return snapshot
+}
+
+// envSnapshotExists reports whether a snapshot has already been saved
+// for machineName.
+func envSnapshotExists(machineName string) bool {
+	_, err := os.Stat(envSnapshotPath(machineName))
+	return err == nil
+}
+
+// saveEnvSnapshot persists snapshot as the restore point for machineName.
+// Errors are non-fatal: the snapshot is a nice-to-have for `--unset`, not
+// something `machine env` itself depends on.
+func saveEnvSnapshot(machineName string, snapshot ShellConfig) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := envSnapshotPath(machineName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// loadEnvSnapshot returns the environment snapshot recorded for the
+// active machine, as named by $DOCKER_MACHINE_NAME, or nil if there is
+// none to restore.
+func loadEnvSnapshot() *ShellConfig {
+	machineName := os.Getenv("DOCKER_MACHINE_NAME")
+	if machineName == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(envSnapshotPath(machineName))
+	if err != nil {
+		return nil
+	}
+
+	var snapshot ShellConfig
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+
+	return &snapshot
+}
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/persist/persisttest"
+	"github.com/stretchr/testify/assert"
+)
+
+// withMachineDir points mcndirs at a throwaway directory for the
+// duration of fn, so snapshot reads/writes in tests never touch the
+// caller's real machine store.
+func withMachineDir(t *testing.T, fn func()) {
+	dir, err := ioutil.TempDir("", "machine-env-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := os.Getenv("MACHINE_STORAGE_PATH")
+	os.Setenv("MACHINE_STORAGE_PATH", dir)
+	defer os.Setenv("MACHINE_STORAGE_PATH", old)
+
+	fn()
+}
+
+func TestEnvSnapshotSaveLoadRoundTrip(t *testing.T) {
+	withMachineDir(t, func() {
+		os.Setenv("DOCKER_MACHINE_NAME", "quux")
+		defer os.Unsetenv("DOCKER_MACHINE_NAME")
+
+		snapshot := ShellConfig{
+			DockerHost:      "tcp://9.9.9.9:2376",
+			DockerCertPath:  "/certs/quux",
+			DockerTLSVerify: "1",
+			MachineName:     "quux",
+		}
+
+		assert.NoError(t, saveEnvSnapshot("quux", snapshot))
+
+		loaded := loadEnvSnapshot()
+		if assert.NotNil(t, loaded) {
+			assert.Equal(t, snapshot.DockerHost, loaded.DockerHost)
+			assert.Equal(t, snapshot.DockerCertPath, loaded.DockerCertPath)
+			assert.Equal(t, snapshot.DockerTLSVerify, loaded.DockerTLSVerify)
+		}
+	})
+}
+
+func TestLoadEnvSnapshotNoActiveMachine(t *testing.T) {
+	withMachineDir(t, func() {
+		os.Unsetenv("DOCKER_MACHINE_NAME")
+		assert.Nil(t, loadEnvSnapshot())
+	})
+}
+
+func TestLoadEnvSnapshotMissingFile(t *testing.T) {
+	withMachineDir(t, func() {
+		os.Setenv("DOCKER_MACHINE_NAME", "never-saved")
+		defer os.Unsetenv("DOCKER_MACHINE_NAME")
+
+		assert.Nil(t, loadEnvSnapshot())
+	})
+}
+
+// TestShellCfgUnsetRestoresSnapshot exercises the restore path described
+// in the TODO that TestShellCfgUnset used to carry: when a snapshot was
+// left behind by a prior `env` invocation for the active machine, --unset
+// restores those values instead of just clearing ours on top of them.
+func TestShellCfgUnsetRestoresSnapshot(t *testing.T) {
+	const usageHint = "This is the unset usage hint"
+
+	defer revertUsageHinter(defaultUsageHinter)
+	defaultUsageHinter = &SimpleUsageHintGenerator{usageHint}
+
+	withMachineDir(t, func() {
+		os.Setenv("DOCKER_MACHINE_NAME", "quux")
+		defer os.Unsetenv("DOCKER_MACHINE_NAME")
+
+		certPath := filepath.Join(mcndirs.GetMachineDir(), "quux")
+		assert.NoError(t, saveEnvSnapshot("quux", ShellConfig{
+			DockerHost:      "tcp://1.2.3.4:2376",
+			DockerCertPath:  certPath,
+			DockerTLSVerify: "1",
+			MachineName:     "quux",
+		}))
+
+		commandLine := &commandstest.FakeCommandLine{
+			CliArgs: nil,
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{
+					"shell":    "bash",
+					"swarm":    false,
+					"no-proxy": false,
+				},
+			},
+		}
+		api := libmachine.API(&libmachinetest.FakeAPI{FakeStore: &persisttest.FakeStore{}})
+		defaultConnChecker = &FakeConnChecker{}
+
+		rendered, err := envRenderUnset(commandLine, api)
+		assert.NoError(t, err)
+		assert.Equal(
+			t,
+			fmt.Sprintf(
+				"export DOCKER_TLS_VERIFY=\"1\"\nexport DOCKER_HOST=\"tcp://1.2.3.4:2376\"\nexport DOCKER_CERT_PATH=\"%s\"\nexport DOCKER_MACHINE_NAME=\"quux\"\n",
+				certPath,
+			),
+			rendered,
+		)
+	})
+}
+
+// TestShellCfgSetTwiceThenUnsetStillUnsets guards against a re-run of
+// `machine env quux` clobbering the restore point with quux's own
+// values: once the calling shell has applied the first run's output,
+// DOCKER_MACHINE_NAME and DOCKER_HOST point at quux, and a naive second
+// snapshot would capture those instead of what came before them.
+func TestShellCfgSetTwiceThenUnsetStillUnsets(t *testing.T) {
+	const usageHint = "This is a usage hint"
+
+	defer revertUsageHinter(defaultUsageHinter)
+	defaultUsageHinter = &SimpleUsageHintGenerator{usageHint}
+
+	withMachineDir(t, func() {
+		os.Unsetenv("DOCKER_MACHINE_NAME")
+		os.Unsetenv("DOCKER_HOST")
+		os.Unsetenv("DOCKER_TLS_VERIFY")
+		os.Unsetenv("DOCKER_CERT_PATH")
+		defer os.Unsetenv("DOCKER_MACHINE_NAME")
+		defer os.Unsetenv("DOCKER_HOST")
+		defer os.Unsetenv("DOCKER_TLS_VERIFY")
+		defer os.Unsetenv("DOCKER_CERT_PATH")
+
+		commandLine := &commandstest.FakeCommandLine{
+			CliArgs: []string{"quux"},
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{
+					"shell":    "bash",
+					"swarm":    false,
+					"no-proxy": false,
+				},
+			},
+		}
+		api := libmachine.API(&libmachinetest.FakeAPI{
+			FakeStore: &persisttest.FakeStore{
+				Hosts: []*host.Host{
+					{Name: "quux"},
+				},
+			},
+		})
+		defaultConnChecker = &FakeConnChecker{DockerHost: "tcp://1.2.3.4:2376"}
+
+		// First run: nothing was set beforehand, so the snapshot taken
+		// should be empty.
+		_, err := shellCfgSet(commandLine, api)
+		assert.NoError(t, err)
+
+		// Simulate the calling shell having applied that output before
+		// running `machine env quux` a second time.
+		os.Setenv("DOCKER_MACHINE_NAME", "quux")
+		os.Setenv("DOCKER_HOST", "tcp://1.2.3.4:2376")
+		os.Setenv("DOCKER_TLS_VERIFY", "1")
+		os.Setenv("DOCKER_CERT_PATH", filepath.Join(mcndirs.GetMachineDir(), "quux"))
+
+		_, err = shellCfgSet(commandLine, api)
+		assert.NoError(t, err)
+
+		unsetCommandLine := &commandstest.FakeCommandLine{
+			CliArgs: nil,
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{
+					"shell":    "bash",
+					"swarm":    false,
+					"no-proxy": false,
+				},
+			},
+		}
+
+		rendered, err := envRenderUnset(unsetCommandLine, api)
+		assert.NoError(t, err)
+		assert.Equal(t, "unset DOCKER_TLS_VERIFY\nunset DOCKER_HOST\nunset DOCKER_CERT_PATH\nunset DOCKER_MACHINE_NAME\n", rendered)
+	})
+}
+
+func TestShellCfgUnsetWithoutSnapshotStillUnsets(t *testing.T) {
+	const usageHint = "This is the unset usage hint"
+
+	defer revertUsageHinter(defaultUsageHinter)
+	defaultUsageHinter = &SimpleUsageHintGenerator{usageHint}
+
+	withMachineDir(t, func() {
+		os.Setenv("DOCKER_MACHINE_NAME", "quux")
+		defer os.Unsetenv("DOCKER_MACHINE_NAME")
+
+		commandLine := &commandstest.FakeCommandLine{
+			CliArgs: nil,
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{
+					"shell":    "bash",
+					"swarm":    false,
+					"no-proxy": false,
+				},
+			},
+		}
+		api := libmachine.API(&libmachinetest.FakeAPI{FakeStore: &persisttest.FakeStore{}})
+		defaultConnChecker = &FakeConnChecker{}
+
+		rendered, err := envRenderUnset(commandLine, api)
+		assert.NoError(t, err)
+		assert.Equal(t, "unset DOCKER_TLS_VERIFY\nunset DOCKER_HOST\nunset DOCKER_CERT_PATH\nunset DOCKER_MACHINE_NAME\n", rendered)
+	})
+}
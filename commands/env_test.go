@@ -1,7 +1,7 @@
 package commands
 
 import (
-	"os"
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -28,6 +28,15 @@ func (fcc *FakeConnChecker) Check(_ *host.Host, _ bool) (string, *auth.Options,
 	return fcc.DockerHost, fcc.AuthOptions, fcc.Err
 }
 
+type FakeProxyConfigSource struct {
+	Cfg libmachine.ProxyConfig
+	Err error
+}
+
+func (fpcs *FakeProxyConfigSource) Get(_ string) (libmachine.ProxyConfig, error) {
+	return fpcs.Cfg, fpcs.Err
+}
+
 type SimpleUsageHintGenerator struct {
 	Hint string
 }
@@ -87,23 +96,31 @@ func TestShellCfgSet(t *testing.T) {
 	defer revertUsageHinter(defaultUsageHinter)
 	defaultUsageHinter = &SimpleUsageHintGenerator{usageHint}
 
+	withMachineDir(t, func() {
+		testShellCfgSet(t, filepath.Join(mcndirs.GetMachineDir(), "quux"))
+	})
+}
+
+// testShellCfgSet holds the actual TestShellCfgSet cases; it runs inside
+// withMachineDir so the saveEnvSnapshot call in shellCfgSet never writes
+// env.json into the caller's real machine store.
+func testShellCfgSet(t *testing.T, certPath string) {
 	var tests = []struct {
-		description      string
-		commandLine      CommandLine
-		api              libmachine.API
-		connChecker      ConnChecker
-		noProxyVar       string
-		noProxyValue     string
-		expectedShellCfg *ShellConfig
-		expectedErr      error
+		description    string
+		commandLine    CommandLine
+		api            libmachine.API
+		connChecker    ConnChecker
+		proxyCfg       libmachine.ProxyConfig
+		expectedOutput string
+		expectedErr    error
 	}{
 		{
 			description: "no host name specified",
 			commandLine: &commandstest.FakeCommandLine{
 				CliArgs: nil,
 			},
-			expectedShellCfg: nil,
-			expectedErr:      errImproperEnvArgs,
+			expectedOutput: "",
+			expectedErr:    errImproperEnvArgs,
 		},
 		{
 			description: "bash shell set happy path without any flags set",
@@ -131,16 +148,10 @@ func TestShellCfgSet(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:          "export ",
-				Delimiter:       "=\"",
-				Suffix:          "\"\n",
-				DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), "quux"),
-				DockerHost:      "tcp://1.2.3.4:2376",
-				DockerTLSVerify: "1",
-				UsageHint:       usageHint,
-				MachineName:     "quux",
-			},
+			expectedOutput: fmt.Sprintf(
+				"export DOCKER_TLS_VERIFY=\"1\"\nexport DOCKER_HOST=\"tcp://1.2.3.4:2376\"\nexport DOCKER_CERT_PATH=\"%s\"\nexport DOCKER_MACHINE_NAME=\"quux\"\n",
+				certPath,
+			),
 			expectedErr: nil,
 		},
 		{
@@ -169,16 +180,10 @@ func TestShellCfgSet(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:          "set -gx ",
-				Suffix:          "\";\n",
-				Delimiter:       " \"",
-				DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), "quux"),
-				DockerHost:      "tcp://1.2.3.4:2376",
-				DockerTLSVerify: "1",
-				UsageHint:       usageHint,
-				MachineName:     "quux",
-			},
+			expectedOutput: fmt.Sprintf(
+				"set -gx DOCKER_TLS_VERIFY \"1\";\nset -gx DOCKER_HOST \"tcp://1.2.3.4:2376\";\nset -gx DOCKER_CERT_PATH \"%s\";\nset -gx DOCKER_MACHINE_NAME \"quux\";\n",
+				certPath,
+			),
 			expectedErr: nil,
 		},
 		{
@@ -207,16 +212,10 @@ func TestShellCfgSet(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:          "$Env:",
-				Suffix:          "\"\n",
-				Delimiter:       " = \"",
-				DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), "quux"),
-				DockerHost:      "tcp://1.2.3.4:2376",
-				DockerTLSVerify: "1",
-				UsageHint:       usageHint,
-				MachineName:     "quux",
-			},
+			expectedOutput: fmt.Sprintf(
+				"$Env:DOCKER_TLS_VERIFY = \"1\"\n$Env:DOCKER_HOST = \"tcp://1.2.3.4:2376\"\n$Env:DOCKER_CERT_PATH = \"%s\"\n$Env:DOCKER_MACHINE_NAME = \"quux\"\n",
+				certPath,
+			),
 			expectedErr: nil,
 		},
 		{
@@ -245,16 +244,10 @@ func TestShellCfgSet(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:          "SET ",
-				Suffix:          "\n",
-				Delimiter:       "=",
-				DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), "quux"),
-				DockerHost:      "tcp://1.2.3.4:2376",
-				DockerTLSVerify: "1",
-				UsageHint:       usageHint,
-				MachineName:     "quux",
-			},
+			expectedOutput: fmt.Sprintf(
+				"SET DOCKER_TLS_VERIFY=1\nSET DOCKER_HOST=tcp://1.2.3.4:2376\nSET DOCKER_CERT_PATH=%s\nSET DOCKER_MACHINE_NAME=quux\n",
+				certPath,
+			),
 			expectedErr: nil,
 		},
 		{
@@ -287,24 +280,14 @@ func TestShellCfgSet(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:          "export ",
-				Delimiter:       "=\"",
-				Suffix:          "\"\n",
-				DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), "quux"),
-				DockerHost:      "tcp://1.2.3.4:2376",
-				DockerTLSVerify: "1",
-				UsageHint:       usageHint,
-				NoProxyVar:      "NO_PROXY",
-				NoProxyValue:    "1.2.3.4", // From FakeDriver
-				MachineName:     "quux",
-			},
-			noProxyVar:   "NO_PROXY",
-			noProxyValue: "",
-			expectedErr:  nil,
+			expectedOutput: fmt.Sprintf(
+				"export DOCKER_TLS_VERIFY=\"1\"\nexport DOCKER_HOST=\"tcp://1.2.3.4:2376\"\nexport DOCKER_CERT_PATH=\"%s\"\nexport DOCKER_MACHINE_NAME=\"quux\"\nexport NO_PROXY=\"1.2.3.4\"\n",
+				certPath,
+			),
+			expectedErr: nil,
 		},
 		{
-			description: "bash shell set happy path with --no-proxy flag; existing environment variable _is_ set",
+			description: "bash shell set happy path with --no-proxy flag; proxy config already has a NoProxy value",
 			commandLine: &commandstest.FakeCommandLine{
 				CliArgs: []string{"quux"},
 				LocalFlags: &commandstest.FakeFlagger{
@@ -333,37 +316,28 @@ func TestShellCfgSet(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:          "export ",
-				Delimiter:       "=\"",
-				Suffix:          "\"\n",
-				DockerCertPath:  filepath.Join(mcndirs.GetMachineDir(), "quux"),
-				DockerHost:      "tcp://1.2.3.4:2376",
-				DockerTLSVerify: "1",
-				UsageHint:       usageHint,
-				NoProxyVar:      "no_proxy",
-				NoProxyValue:    "192.168.59.1,1.2.3.4", // From FakeDriver
-				MachineName:     "quux",
-			},
-			noProxyVar:   "no_proxy",
-			noProxyValue: "192.168.59.1",
-			expectedErr:  nil,
+			proxyCfg: libmachine.ProxyConfig{
+				NoProxy: "192.168.59.1",
+			},
+			expectedOutput: fmt.Sprintf(
+				"export DOCKER_TLS_VERIFY=\"1\"\nexport DOCKER_HOST=\"tcp://1.2.3.4:2376\"\nexport DOCKER_CERT_PATH=\"%s\"\nexport DOCKER_MACHINE_NAME=\"quux\"\nexport NO_PROXY=\"192.168.59.1,1.2.3.4\"\n",
+				certPath,
+			),
+			expectedErr: nil,
 		},
 	}
 
-	for _, test := range tests {
-		// TODO: Ideally this should not hit the environment at all but
-		// rather should go through an interface.
-		os.Setenv(test.noProxyVar, test.noProxyValue)
+	defer func(old ProxyConfigSource) { defaultProxyConfigSource = old }(defaultProxyConfigSource)
 
+	for _, test := range tests {
 		t.Log(test.description)
 
 		defaultConnChecker = test.connChecker
-		shellCfg, err := shellCfgSet(test.commandLine, test.api)
-		assert.Equal(t, test.expectedShellCfg, shellCfg)
-		assert.Equal(t, test.expectedErr, err)
+		defaultProxyConfigSource = &FakeProxyConfigSource{Cfg: test.proxyCfg}
 
-		os.Unsetenv(test.noProxyVar)
+		rendered, err := envRenderSet(test.commandLine, test.api)
+		assert.Equal(t, test.expectedOutput, rendered)
+		assert.Equal(t, test.expectedErr, err)
 	}
 }
 
@@ -376,22 +350,20 @@ func TestShellCfgUnset(t *testing.T) {
 	defaultUsageHinter = &SimpleUsageHintGenerator{usageHint}
 
 	var tests = []struct {
-		description      string
-		commandLine      CommandLine
-		api              libmachine.API
-		connChecker      ConnChecker
-		noProxyVar       string
-		noProxyValue     string
-		expectedShellCfg *ShellConfig
-		expectedErr      error
+		description    string
+		commandLine    CommandLine
+		api            libmachine.API
+		connChecker    ConnChecker
+		expectedOutput string
+		expectedErr    error
 	}{
 		{
 			description: "more than expected args passed in",
 			commandLine: &commandstest.FakeCommandLine{
 				CliArgs: []string{"foo", "bar"},
 			},
-			expectedShellCfg: nil,
-			expectedErr:      errImproperUnsetEnvArgs,
+			expectedOutput: "",
+			expectedErr:    errImproperUnsetEnvArgs,
 		},
 		{
 			description: "bash shell unset happy path without any flags set",
@@ -411,13 +383,8 @@ func TestShellCfgUnset(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:    "unset ",
-				Suffix:    "\n",
-				Delimiter: "",
-				UsageHint: usageHint,
-			},
-			expectedErr: nil,
+			expectedOutput: "unset DOCKER_TLS_VERIFY\nunset DOCKER_HOST\nunset DOCKER_CERT_PATH\nunset DOCKER_MACHINE_NAME\n",
+			expectedErr:    nil,
 		},
 		{
 			description: "fish shell unset happy path",
@@ -439,13 +406,8 @@ func TestShellCfgUnset(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:    "set -e ",
-				Suffix:    ";\n",
-				Delimiter: "",
-				UsageHint: usageHint,
-			},
-			expectedErr: nil,
+			expectedOutput: "set -e DOCKER_TLS_VERIFY;\nset -e DOCKER_HOST;\nset -e DOCKER_CERT_PATH;\nset -e DOCKER_MACHINE_NAME;\n",
+			expectedErr:    nil,
 		},
 		{
 			description: "powershell unset happy path",
@@ -465,12 +427,11 @@ func TestShellCfgUnset(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:    `Remove-Item Env:\\`,
-				Suffix:    "\n",
-				Delimiter: "",
-				UsageHint: usageHint,
-			},
+			expectedOutput: `Remove-Item Env:\\DOCKER_TLS_VERIFY
+Remove-Item Env:\\DOCKER_HOST
+Remove-Item Env:\\DOCKER_CERT_PATH
+Remove-Item Env:\\DOCKER_MACHINE_NAME
+`,
 			expectedErr: nil,
 		},
 		{
@@ -493,31 +454,154 @@ func TestShellCfgUnset(t *testing.T) {
 				AuthOptions: nil,
 				Err:         nil,
 			},
-			expectedShellCfg: &ShellConfig{
-				Prefix:    "SET ",
-				Suffix:    "\n",
-				Delimiter: "=",
-				UsageHint: usageHint,
-			},
-			expectedErr: nil,
+			expectedOutput: "SET DOCKER_TLS_VERIFY=\nSET DOCKER_HOST=\nSET DOCKER_CERT_PATH=\nSET DOCKER_MACHINE_NAME=\n",
+			expectedErr:    nil,
 		},
-		// TODO: There is kind of a funny bug (feature?) I discovered
-		// reasoning about unset() where if there was a NO_PROXY value
-		// set _before_ the original docker-machine env, it won't be
-		// restored (NO_PROXY won't be unset at all, it will stay the
-		// same).  We should define expected behavior in this case.
 	}
 
 	for _, test := range tests {
-		os.Setenv(test.noProxyVar, test.noProxyValue)
+		t.Log(test.description)
 
+		defaultConnChecker = test.connChecker
+		rendered, err := envRenderUnset(test.commandLine, test.api)
+		assert.Equal(t, test.expectedOutput, rendered)
+		assert.Equal(t, test.expectedErr, err)
+	}
+}
+
+// fakeSSHDriver wraps fakedriver.Driver to additionally stub the SSH
+// connection details podman-env depends on, since fakedriver.Driver
+// itself always reports the zero value for those.
+type fakeSSHDriver struct {
+	*fakedriver.Driver
+	SSHHostname string
+	SSHPort     int
+	SSHUser     string
+	SSHKeyPath  string
+}
+
+func (d *fakeSSHDriver) GetSSHHostname() (string, error) {
+	return d.SSHHostname, nil
+}
+
+func (d *fakeSSHDriver) GetSSHPort() (int, error) {
+	return d.SSHPort, nil
+}
+
+func (d *fakeSSHDriver) GetSSHUsername() string {
+	return d.SSHUser
+}
+
+func (d *fakeSSHDriver) GetSSHKeyPath() string {
+	return d.SSHKeyPath
+}
+
+func TestPodmanShellCfgSet(t *testing.T) {
+	const usageHint = "This is a usage hint"
+
+	defer revertUsageHinter(defaultUsageHinter)
+	defaultUsageHinter = &SimpleUsageHintGenerator{usageHint}
+
+	var tests = []struct {
+		description    string
+		commandLine    CommandLine
+		api            libmachine.API
+		connChecker    ConnChecker
+		expectedOutput string
+		expectedErr    error
+	}{
+		{
+			description: "no host name specified",
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: nil,
+			},
+			expectedOutput: "",
+			expectedErr:    errImproperEnvArgs,
+		},
+		{
+			description: "bash shell socket URL happy path, non-standard SSH port",
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"quux"},
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"shell":   "bash",
+						"swarm":   false,
+						"varlink": false,
+					},
+				},
+			},
+			api: &libmachinetest.FakeAPI{
+				FakeStore: &persisttest.FakeStore{
+					Hosts: []*host.Host{
+						{
+							Name: "quux",
+							Driver: &fakeSSHDriver{
+								Driver:      &fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"},
+								SSHHostname: "1.2.3.4",
+								SSHPort:     2222,
+								SSHUser:     "docker",
+								SSHKeyPath:  "/certs/quux/id_rsa",
+							},
+						},
+					},
+				},
+			},
+			connChecker: &FakeConnChecker{
+				DockerHost: "tcp://1.2.3.4:2376",
+			},
+			expectedOutput: "export CONTAINER_HOST=\"ssh://docker@1.2.3.4:2222/run/podman/podman.sock\"\nexport CONTAINER_SSHKEY=\"/certs/quux/id_rsa\"\n",
+			expectedErr:    nil,
+		},
+		{
+			description: "bash shell varlink bridge happy path, non-standard SSH port",
+			commandLine: &commandstest.FakeCommandLine{
+				CliArgs: []string{"quux"},
+				LocalFlags: &commandstest.FakeFlagger{
+					Data: map[string]interface{}{
+						"shell":   "bash",
+						"swarm":   false,
+						"varlink": true,
+					},
+				},
+			},
+			api: &libmachinetest.FakeAPI{
+				FakeStore: &persisttest.FakeStore{
+					Hosts: []*host.Host{
+						{
+							Name: "quux",
+							Driver: &fakeSSHDriver{
+								Driver:      &fakedriver.Driver{MockState: state.Running, MockIP: "1.2.3.4"},
+								SSHHostname: "1.2.3.4",
+								SSHPort:     2222,
+								SSHUser:     "docker",
+								SSHKeyPath:  "/certs/quux/id_rsa",
+							},
+						},
+					},
+				},
+			},
+			connChecker: &FakeConnChecker{
+				DockerHost: "tcp://1.2.3.4:2376",
+			},
+			expectedOutput: "export CONTAINER_HOST=\"ssh://docker@1.2.3.4:2222/run/podman/podman.sock\"\nexport CONTAINER_SSHKEY=\"/certs/quux/id_rsa\"\nexport PODMAN_VARLINK_BRIDGE=\"ssh -p 2222 -i /certs/quux/id_rsa docker@1.2.3.4 -- sudo varlink bridge\"\n",
+			expectedErr:    nil,
+		},
+	}
+
+	for _, test := range tests {
 		t.Log(test.description)
 
 		defaultConnChecker = test.connChecker
-		shellCfg, err := shellCfgUnset(test.commandLine, test.api)
-		assert.Equal(t, test.expectedShellCfg, shellCfg)
+
+		shellCfg, err := podmanShellCfgSet(test.commandLine, test.api)
 		assert.Equal(t, test.expectedErr, err)
 
-		os.Setenv(test.noProxyVar, "")
+		if err != nil {
+			continue
+		}
+
+		rendered, err := podmanRendererForShell(resolveShell(test.commandLine)).RenderSet(*shellCfg)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedOutput, rendered)
 	}
 }
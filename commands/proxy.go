@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/libmachine"
+)
+
+var errImproperProxyArgs = errors.New("Error: Expected one machine name as an argument")
+
+func cmdProxySet(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return errImproperProxyArgs
+	}
+	machineName := c.Args()[0]
+
+	cfg, err := loadProxyConfig(machineName)
+	if err != nil {
+		return err
+	}
+
+	if v := c.String("http-proxy"); v != "" {
+		cfg.HTTPProxy = v
+	}
+	if v := c.String("https-proxy"); v != "" {
+		cfg.HTTPSProxy = v
+	}
+	if v := c.String("no-proxy"); v != "" {
+		cfg.NoProxy = v
+	}
+	if v := c.String("no-proxy-cidrs"); v != "" {
+		cfg.NoProxyCIDRs = strings.Split(v, ",")
+	}
+
+	return saveProxyConfig(machineName, cfg)
+}
+
+func cmdProxyGet(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return errImproperProxyArgs
+	}
+	machineName := c.Args()[0]
+
+	cfg, err := loadProxyConfig(machineName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("HTTP_PROXY=%s\n", cfg.HTTPProxy)
+	fmt.Printf("HTTPS_PROXY=%s\n", cfg.HTTPSProxy)
+	fmt.Printf("NO_PROXY=%s\n", cfg.NoProxy)
+	fmt.Printf("NO_PROXY_CIDRS=%s\n", strings.Join(cfg.NoProxyCIDRs, ","))
+
+	return nil
+}
+
+func cmdProxyUnset(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return errImproperProxyArgs
+	}
+	machineName := c.Args()[0]
+
+	return saveProxyConfig(machineName, libmachine.ProxyConfig{})
+}
+
+// ProxyCommand is the parent `machine proxy` command: it manages the
+// per-machine ProxyConfig that `machine env --no-proxy` composes its
+// NO_PROXY output from.
+var ProxyCommand = cli.Command{
+	Name:  "proxy",
+	Usage: "Manage the proxy configuration for a machine",
+	Subcommands: []cli.Command{
+		{
+			Name:   "set",
+			Usage:  "Set the proxy configuration for a machine",
+			Action: runCommand(cmdProxySet),
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "http-proxy",
+					Usage: "The proxy to use for HTTP requests",
+				},
+				cli.StringFlag{
+					Name:  "https-proxy",
+					Usage: "The proxy to use for HTTPS requests",
+				},
+				cli.StringFlag{
+					Name:  "no-proxy",
+					Usage: "Hosts that should bypass the proxy",
+				},
+				cli.StringFlag{
+					Name:  "no-proxy-cidrs",
+					Usage: "Comma-separated CIDRs that should always bypass the proxy",
+				},
+			},
+		},
+		{
+			Name:   "get",
+			Usage:  "Print the proxy configuration for a machine",
+			Action: runCommand(cmdProxyGet),
+		},
+		{
+			Name:   "unset",
+			Usage:  "Clear the proxy configuration for a machine",
+			Action: runCommand(cmdProxyUnset),
+		},
+	},
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+)
+
+// ProxyConfigSource resolves the libmachine.ProxyConfig for a machine.
+// shellCfgSet consults it instead of reading NO_PROXY/no_proxy directly
+// out of the process environment, so the merged value it produces is
+// deterministic and testable.
+type ProxyConfigSource interface {
+	Get(machineName string) (libmachine.ProxyConfig, error)
+}
+
+// FileProxyConfigSource is the default ProxyConfigSource: it reads the
+// config persisted per-machine by `machine proxy set`.
+type FileProxyConfigSource struct{}
+
+func (FileProxyConfigSource) Get(machineName string) (libmachine.ProxyConfig, error) {
+	cfg, err := loadProxyConfig(machineName)
+	if err != nil {
+		return libmachine.ProxyConfig{}, err
+	}
+	return cfg, nil
+}
+
+var defaultProxyConfigSource ProxyConfigSource = FileProxyConfigSource{}
+
+// proxyConfigFile is the name of the file, relative to a machine's
+// directory, that `machine proxy set/get/unset` manage.
+const proxyConfigFile = "proxy.json"
+
+func proxyConfigPath(machineName string) string {
+	return filepath.Join(mcndirs.GetMachineDir(), machineName, proxyConfigFile)
+}
+
+// loadProxyConfig returns the persisted ProxyConfig for machineName, or
+// the zero value if none has been set yet.
+func loadProxyConfig(machineName string) (libmachine.ProxyConfig, error) {
+	data, err := ioutil.ReadFile(proxyConfigPath(machineName))
+	if os.IsNotExist(err) {
+		return libmachine.ProxyConfig{}, nil
+	}
+	if err != nil {
+		return libmachine.ProxyConfig{}, err
+	}
+
+	var cfg libmachine.ProxyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return libmachine.ProxyConfig{}, err
+	}
+	return cfg, nil
+}
+
+// saveProxyConfig persists cfg as the ProxyConfig for machineName.
+func saveProxyConfig(machineName string, cfg libmachine.ProxyConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := proxyConfigPath(machineName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// resolveNoProxy merges a machine's persisted ProxyConfig into a single
+// NO_PROXY value: the explicitly configured NoProxy, then every entry of
+// NoProxyCIDRs, then ip unless it already appears verbatim among those
+// entries. ip is not matched against the CIDR ranges.
+func resolveNoProxy(cfg libmachine.ProxyConfig, ip string) string {
+	entries := []string{}
+	if cfg.NoProxy != "" {
+		entries = append(entries, cfg.NoProxy)
+	}
+	entries = append(entries, cfg.NoProxyCIDRs...)
+
+	joined := strings.Join(entries, ",")
+
+	alreadyPresent := false
+	for _, entry := range strings.Split(joined, ",") {
+		if entry == ip {
+			alreadyPresent = true
+			break
+		}
+	}
+
+	if ip != "" && !alreadyPresent {
+		if joined != "" {
+			joined = joined + "," + ip
+		} else {
+			joined = ip
+		}
+	}
+
+	return joined
+}
@@ -0,0 +1,73 @@
+// Package shell detects which shell a `machine env`/`machine podman-env`
+// invocation is running under, so the caller can pick the right
+// ShellRenderer without the user having to pass --shell explicitly.
+package shell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Detect returns the name of the calling process's shell (e.g. "bash",
+// "fish", "zsh", "powershell", "cmd") and, where cheaply available, its
+// version. The version is frequently unknown and returned as "".
+func Detect() (string, string, error) {
+	if runtime.GOOS == "windows" {
+		return detectWindows()
+	}
+	return detectUnix()
+}
+
+func detectUnix() (string, string, error) {
+	if shellPath := os.Getenv("SHELL"); shellPath != "" {
+		return filepath.Base(shellPath), "", nil
+	}
+
+	name, err := parentProcessName()
+	if err != nil {
+		return "", "", fmt.Errorf("Error detecting shell from parent process: %s", err)
+	}
+
+	return name, "", nil
+}
+
+// parentProcessName looks up the name of the process that invoked us,
+// which is usually the interactive shell even when $SHELL is unset
+// (e.g. it was never exported). /proc is consulted first since it
+// requires no subprocess; `ps` is the fallback for platforms without
+// it (e.g. macOS).
+func parentProcessName() (string, error) {
+	ppid := os.Getppid()
+
+	if comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", ppid)); err == nil {
+		return strings.TrimSpace(string(comm)), nil
+	}
+
+	out, err := exec.Command("ps", "-p", strconv.Itoa(ppid), "-o", "comm=").Output()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Base(strings.TrimSpace(string(out))), nil
+}
+
+func detectWindows() (string, string, error) {
+	if os.Getenv("PSModulePath") != "" {
+		return "powershell", "", nil
+	}
+
+	if comSpec := os.Getenv("ComSpec"); comSpec != "" {
+		// ComSpec is a Windows path; split on either separator so the
+		// result does not depend on the host's path rules.
+		base := comSpec[strings.LastIndexAny(comSpec, `\/`)+1:]
+		return strings.TrimSuffix(base, filepath.Ext(base)), "", nil
+	}
+
+	return "cmd", "", nil
+}
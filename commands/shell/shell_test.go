@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withEnv(t *testing.T, key, value string, fn func()) {
+	old, hadOld := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	defer func() {
+		if hadOld {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}()
+
+	fn()
+}
+
+func TestDetectUnixFromShellEnv(t *testing.T) {
+	withEnv(t, "SHELL", "/usr/bin/zsh", func() {
+		name, version, err := detectUnix()
+		assert.NoError(t, err)
+		assert.Equal(t, "zsh", name)
+		assert.Equal(t, "", version)
+	})
+}
+
+func TestDetectWindowsPowershell(t *testing.T) {
+	withEnv(t, "PSModulePath", `C:\Windows\system32\WindowsPowerShell\v1.0\Modules`, func() {
+		name, _, err := detectWindows()
+		assert.NoError(t, err)
+		assert.Equal(t, "powershell", name)
+	})
+}
+
+func TestDetectWindowsCmdFromComSpec(t *testing.T) {
+	withEnv(t, "PSModulePath", "", func() {
+		withEnv(t, "ComSpec", `C:\Windows\system32\cmd.exe`, func() {
+			name, _, err := detectWindows()
+			assert.NoError(t, err)
+			assert.Equal(t, "cmd", name)
+		})
+	})
+}
+
+func TestDetectWindowsDefaultsToCmd(t *testing.T) {
+	withEnv(t, "PSModulePath", "", func() {
+		withEnv(t, "ComSpec", "", func() {
+			name, _, err := detectWindows()
+			assert.NoError(t, err)
+			assert.Equal(t, "cmd", name)
+		})
+	})
+}
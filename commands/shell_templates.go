@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// ShellRenderer turns a ShellConfig into the literal script a shell can
+// source (or pipe through eval) to set or unset the Docker client
+// environment variables. Each supported shell registers its own
+// implementation in shellRenderers below, so that adding a new shell
+// never requires touching shellCfgSet/shellCfgUnset.
+type ShellRenderer interface {
+	RenderSet(cfg ShellConfig) (string, error)
+	RenderUnset(cfg ShellConfig) (string, error)
+}
+
+// templateShellRenderer is a ShellRenderer backed by a pair of
+// text/template programs, one for the set form and one for the unset
+// form.
+type templateShellRenderer struct {
+	setTemplate   *template.Template
+	unsetTemplate *template.Template
+}
+
+func newTemplateShellRenderer(name, setText, unsetText string) *templateShellRenderer {
+	return &templateShellRenderer{
+		setTemplate:   template.Must(template.New(name + "-set").Parse(setText)),
+		unsetTemplate: template.Must(template.New(name + "-unset").Parse(unsetText)),
+	}
+}
+
+func (r *templateShellRenderer) RenderSet(cfg ShellConfig) (string, error) {
+	var buf bytes.Buffer
+	if err := r.setTemplate.Execute(&buf, cfg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (r *templateShellRenderer) RenderUnset(cfg ShellConfig) (string, error) {
+	var buf bytes.Buffer
+	if err := r.unsetTemplate.Execute(&buf, cfg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+const bashSetTemplate = `export DOCKER_TLS_VERIFY="{{.DockerTLSVerify}}"
+export DOCKER_HOST="{{.DockerHost}}"
+export DOCKER_CERT_PATH="{{.DockerCertPath}}"
+export DOCKER_MACHINE_NAME="{{.MachineName}}"
+{{if .NoProxyVar}}export {{.NoProxyVar}}="{{.NoProxyValue}}"
+{{end}}`
+
+const bashUnsetTemplate = `unset DOCKER_TLS_VERIFY
+unset DOCKER_HOST
+unset DOCKER_CERT_PATH
+unset DOCKER_MACHINE_NAME
+`
+
+const fishSetTemplate = `set -gx DOCKER_TLS_VERIFY "{{.DockerTLSVerify}}";
+set -gx DOCKER_HOST "{{.DockerHost}}";
+set -gx DOCKER_CERT_PATH "{{.DockerCertPath}}";
+set -gx DOCKER_MACHINE_NAME "{{.MachineName}}";
+{{if .NoProxyVar}}set -gx {{.NoProxyVar}} "{{.NoProxyValue}}";
+{{end}}`
+
+const fishUnsetTemplate = `set -e DOCKER_TLS_VERIFY;
+set -e DOCKER_HOST;
+set -e DOCKER_CERT_PATH;
+set -e DOCKER_MACHINE_NAME;
+`
+
+const powershellSetTemplate = `$Env:DOCKER_TLS_VERIFY = "{{.DockerTLSVerify}}"
+$Env:DOCKER_HOST = "{{.DockerHost}}"
+$Env:DOCKER_CERT_PATH = "{{.DockerCertPath}}"
+$Env:DOCKER_MACHINE_NAME = "{{.MachineName}}"
+{{if .NoProxyVar}}$Env:{{.NoProxyVar}} = "{{.NoProxyValue}}"
+{{end}}`
+
+const powershellUnsetTemplate = `Remove-Item Env:\\DOCKER_TLS_VERIFY
+Remove-Item Env:\\DOCKER_HOST
+Remove-Item Env:\\DOCKER_CERT_PATH
+Remove-Item Env:\\DOCKER_MACHINE_NAME
+`
+
+const cmdSetTemplate = `SET DOCKER_TLS_VERIFY={{.DockerTLSVerify}}
+SET DOCKER_HOST={{.DockerHost}}
+SET DOCKER_CERT_PATH={{.DockerCertPath}}
+SET DOCKER_MACHINE_NAME={{.MachineName}}
+{{if .NoProxyVar}}SET {{.NoProxyVar}}={{.NoProxyValue}}
+{{end}}`
+
+const cmdUnsetTemplate = `SET DOCKER_TLS_VERIFY=
+SET DOCKER_HOST=
+SET DOCKER_CERT_PATH=
+SET DOCKER_MACHINE_NAME=
+`
+
+const tcshSetTemplate = `setenv DOCKER_TLS_VERIFY "{{.DockerTLSVerify}}";
+setenv DOCKER_HOST "{{.DockerHost}}";
+setenv DOCKER_CERT_PATH "{{.DockerCertPath}}";
+setenv DOCKER_MACHINE_NAME "{{.MachineName}}";
+{{if .NoProxyVar}}setenv {{.NoProxyVar}} "{{.NoProxyValue}}";
+{{end}}`
+
+const tcshUnsetTemplate = `unsetenv DOCKER_TLS_VERIFY;
+unsetenv DOCKER_HOST;
+unsetenv DOCKER_CERT_PATH;
+unsetenv DOCKER_MACHINE_NAME;
+`
+
+const nushellSetTemplate = `$env.DOCKER_TLS_VERIFY = "{{.DockerTLSVerify}}"
+$env.DOCKER_HOST = "{{.DockerHost}}"
+$env.DOCKER_CERT_PATH = "{{.DockerCertPath}}"
+$env.DOCKER_MACHINE_NAME = "{{.MachineName}}"
+{{if .NoProxyVar}}$env.{{.NoProxyVar}} = "{{.NoProxyValue}}"
+{{end}}`
+
+const nushellUnsetTemplate = `hide-env DOCKER_TLS_VERIFY
+hide-env DOCKER_HOST
+hide-env DOCKER_CERT_PATH
+hide-env DOCKER_MACHINE_NAME
+`
+
+// shellRenderers is the registry of known shells, keyed by the same
+// name used for the `--shell` flag and reported by shell detection.
+// Shells that share POSIX export syntax (zsh) get a renderer built
+// from the bash template text rather than templates of their own.
+var shellRenderers = map[string]ShellRenderer{
+	"bash":       newTemplateShellRenderer("bash", bashSetTemplate, bashUnsetTemplate),
+	"zsh":        newTemplateShellRenderer("bash", bashSetTemplate, bashUnsetTemplate),
+	"fish":       newTemplateShellRenderer("fish", fishSetTemplate, fishUnsetTemplate),
+	"powershell": newTemplateShellRenderer("powershell", powershellSetTemplate, powershellUnsetTemplate),
+	"cmd":        newTemplateShellRenderer("cmd", cmdSetTemplate, cmdUnsetTemplate),
+	"tcsh":       newTemplateShellRenderer("tcsh", tcshSetTemplate, tcshUnsetTemplate),
+	"nushell":    newTemplateShellRenderer("nushell", nushellSetTemplate, nushellUnsetTemplate),
+}
+
+// rendererForShell looks up the ShellRenderer for the given shell name,
+// falling back to the bash/POSIX renderer for "", "sh", and any shell we
+// don't have a dedicated template for.
+func rendererForShell(userShell string) ShellRenderer {
+	if r, ok := shellRenderers[userShell]; ok {
+		return r
+	}
+	return shellRenderers["bash"]
+}
+
+// podmanShellRenderer is the podman-env analogue of ShellRenderer: same
+// idea, different variable names (CONTAINER_* rather than DOCKER_*).
+type podmanShellRenderer interface {
+	RenderSet(cfg podmanShellConfig) (string, error)
+	RenderUnset(cfg podmanShellConfig) (string, error)
+}
+
+type templatePodmanShellRenderer struct {
+	setTemplate   *template.Template
+	unsetTemplate *template.Template
+}
+
+func newTemplatePodmanShellRenderer(name, setText, unsetText string) *templatePodmanShellRenderer {
+	return &templatePodmanShellRenderer{
+		setTemplate:   template.Must(template.New(name + "-podman-set").Parse(setText)),
+		unsetTemplate: template.Must(template.New(name + "-podman-unset").Parse(unsetText)),
+	}
+}
+
+func (r *templatePodmanShellRenderer) RenderSet(cfg podmanShellConfig) (string, error) {
+	var buf bytes.Buffer
+	if err := r.setTemplate.Execute(&buf, cfg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (r *templatePodmanShellRenderer) RenderUnset(cfg podmanShellConfig) (string, error) {
+	var buf bytes.Buffer
+	if err := r.unsetTemplate.Execute(&buf, cfg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+const bashPodmanSetTemplate = `export CONTAINER_HOST="{{.ContainerHost}}"
+export CONTAINER_SSHKEY="{{.ContainerSSHKey}}"
+{{if .PodmanVarlinkBridge}}export PODMAN_VARLINK_BRIDGE="{{.PodmanVarlinkBridge}}"
+{{end}}`
+
+const bashPodmanUnsetTemplate = `unset CONTAINER_HOST
+unset CONTAINER_SSHKEY
+unset PODMAN_VARLINK_BRIDGE
+`
+
+const fishPodmanSetTemplate = `set -gx CONTAINER_HOST "{{.ContainerHost}}";
+set -gx CONTAINER_SSHKEY "{{.ContainerSSHKey}}";
+{{if .PodmanVarlinkBridge}}set -gx PODMAN_VARLINK_BRIDGE "{{.PodmanVarlinkBridge}}";
+{{end}}`
+
+const fishPodmanUnsetTemplate = `set -e CONTAINER_HOST;
+set -e CONTAINER_SSHKEY;
+set -e PODMAN_VARLINK_BRIDGE;
+`
+
+const powershellPodmanSetTemplate = `$Env:CONTAINER_HOST = "{{.ContainerHost}}"
+$Env:CONTAINER_SSHKEY = "{{.ContainerSSHKey}}"
+{{if .PodmanVarlinkBridge}}$Env:PODMAN_VARLINK_BRIDGE = "{{.PodmanVarlinkBridge}}"
+{{end}}`
+
+const powershellPodmanUnsetTemplate = `Remove-Item Env:\\CONTAINER_HOST
+Remove-Item Env:\\CONTAINER_SSHKEY
+Remove-Item Env:\\PODMAN_VARLINK_BRIDGE
+`
+
+const cmdPodmanSetTemplate = `SET CONTAINER_HOST={{.ContainerHost}}
+SET CONTAINER_SSHKEY={{.ContainerSSHKey}}
+{{if .PodmanVarlinkBridge}}SET PODMAN_VARLINK_BRIDGE={{.PodmanVarlinkBridge}}
+{{end}}`
+
+const cmdPodmanUnsetTemplate = `SET CONTAINER_HOST=
+SET CONTAINER_SSHKEY=
+SET PODMAN_VARLINK_BRIDGE=
+`
+
+const tcshPodmanSetTemplate = `setenv CONTAINER_HOST "{{.ContainerHost}}";
+setenv CONTAINER_SSHKEY "{{.ContainerSSHKey}}";
+{{if .PodmanVarlinkBridge}}setenv PODMAN_VARLINK_BRIDGE "{{.PodmanVarlinkBridge}}";
+{{end}}`
+
+const tcshPodmanUnsetTemplate = `unsetenv CONTAINER_HOST;
+unsetenv CONTAINER_SSHKEY;
+unsetenv PODMAN_VARLINK_BRIDGE;
+`
+
+const nushellPodmanSetTemplate = `$env.CONTAINER_HOST = "{{.ContainerHost}}"
+$env.CONTAINER_SSHKEY = "{{.ContainerSSHKey}}"
+{{if .PodmanVarlinkBridge}}$env.PODMAN_VARLINK_BRIDGE = "{{.PodmanVarlinkBridge}}"
+{{end}}`
+
+const nushellPodmanUnsetTemplate = `hide-env CONTAINER_HOST
+hide-env CONTAINER_SSHKEY
+hide-env PODMAN_VARLINK_BRIDGE
+`
+
+var podmanShellRenderers = map[string]podmanShellRenderer{
+	"bash":       newTemplatePodmanShellRenderer("bash", bashPodmanSetTemplate, bashPodmanUnsetTemplate),
+	"zsh":        newTemplatePodmanShellRenderer("bash", bashPodmanSetTemplate, bashPodmanUnsetTemplate),
+	"fish":       newTemplatePodmanShellRenderer("fish", fishPodmanSetTemplate, fishPodmanUnsetTemplate),
+	"powershell": newTemplatePodmanShellRenderer("powershell", powershellPodmanSetTemplate, powershellPodmanUnsetTemplate),
+	"cmd":        newTemplatePodmanShellRenderer("cmd", cmdPodmanSetTemplate, cmdPodmanUnsetTemplate),
+	"tcsh":       newTemplatePodmanShellRenderer("tcsh", tcshPodmanSetTemplate, tcshPodmanUnsetTemplate),
+	"nushell":    newTemplatePodmanShellRenderer("nushell", nushellPodmanSetTemplate, nushellPodmanUnsetTemplate),
+}
+
+func podmanRendererForShell(userShell string) podmanShellRenderer {
+	if r, ok := podmanShellRenderers[userShell]; ok {
+		return r
+	}
+	return podmanShellRenderers["bash"]
+}
@@ -0,0 +1,24 @@
+package libmachine
+
+// ProxyConfig holds the proxy settings for a single machine: the
+// upstream proxies the Docker client should use when talking through it,
+// and the set of hosts/CIDRs that should always bypass the proxy in
+// addition to whatever the user configures explicitly. It is the
+// persisted alternative to reading NO_PROXY out of the calling process's
+// environment.
+type ProxyConfig struct {
+	HTTPProxy  string `json:"HTTPProxy,omitempty"`
+	HTTPSProxy string `json:"HTTPSProxy,omitempty"`
+	NoProxy    string `json:"NoProxy,omitempty"`
+
+	// NoProxyCIDRs are always folded into NoProxy when it is resolved,
+	// e.g. so a machine's own driver-reported subnet is never
+	// accidentally proxied even if the user never set --no-proxy.
+	NoProxyCIDRs []string `json:"NoProxyCIDRs,omitempty"`
+}
+
+// IsEmpty reports whether the config carries no proxy settings at all,
+// i.e. is indistinguishable from a machine that has never had one set.
+func (pc ProxyConfig) IsEmpty() bool {
+	return pc.HTTPProxy == "" && pc.HTTPSProxy == "" && pc.NoProxy == "" && len(pc.NoProxyCIDRs) == 0
+}